@@ -0,0 +1,113 @@
+package tpl
+
+import (
+	"encoding/binary"
+	"image"
+)
+
+// ToCMP converts an image.Image to CMP (DXT1/BC1) TPL format. Pixels are
+// tiled in 8x8 super-tiles of four 4x4 DXT1 blocks, with the color
+// endpoints and index words stored big-endian per the Wii's block
+// ordering.
+func ToCMP(img image.Image) ([]byte, error) {
+	raw := imageToRGBA(img)
+
+	width := img.Bounds().Max.X
+	height := img.Bounds().Max.Y
+	tilesWide := addPadding(width, 8) / 8
+	tilesHigh := addPadding(height, 8) / 8
+	output := make([]byte, tilesWide*tilesHigh*32)
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 8 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for by := 0; by < 2; by++ {
+				for bx := 0; bx < 2; bx++ {
+					encodeDXT1Block(raw, width, height, x1+bx*4, y1+by*4, output[inp:inp+8])
+					inp += 8
+				}
+			}
+		}
+	}
+
+	return makeTPLHeader(output, CMP, width, height)
+}
+
+// encodeDXT1Block encodes the 4x4 block of raw anchored at (ox, oy) into a
+// big-endian DXT1/BC1 block.
+func encodeDXT1Block(raw []uint32, width, height, ox, oy int, block []byte) {
+	var r, g, b [16]byte
+	for i := 0; i < 16; i++ {
+		x := ox + i%4
+		y := oy + i/4
+		r[i], g[i], b[i], _ = rgbaAt(raw, width, height, x, y)
+	}
+
+	c0, c1 := dxt1Endpoints(r, g, b)
+	rgb0 := rgb565ToNRGBA(c0)
+	rgb1 := rgb565ToNRGBA(c1)
+
+	palette := [4][3]int{
+		{int(rgb0.R), int(rgb0.G), int(rgb0.B)},
+		{int(rgb1.R), int(rgb1.G), int(rgb1.B)},
+		{(2*int(rgb0.R) + int(rgb1.R)) / 3, (2*int(rgb0.G) + int(rgb1.G)) / 3, (2*int(rgb0.B) + int(rgb1.B)) / 3},
+		{(int(rgb0.R) + 2*int(rgb1.R)) / 3, (int(rgb0.G) + 2*int(rgb1.G)) / 3, (int(rgb0.B) + 2*int(rgb1.B)) / 3},
+	}
+
+	var indices uint32
+	for i := 0; i < 16; i++ {
+		best, bestDist := 0, -1
+		for p := 0; p < 4; p++ {
+			dr := int(r[i]) - palette[p][0]
+			dg := int(g[i]) - palette[p][1]
+			db := int(b[i]) - palette[p][2]
+			dist := dr*dr + dg*dg + db*db
+
+			if bestDist == -1 || dist < bestDist {
+				best, bestDist = p, dist
+			}
+		}
+
+		indices |= uint32(best) << uint(30-i*2)
+	}
+
+	binary.BigEndian.PutUint16(block[0:], c0)
+	binary.BigEndian.PutUint16(block[2:], c1)
+	binary.BigEndian.PutUint32(block[4:], indices)
+}
+
+// dxt1Endpoints picks the two RGB565 endpoint colors for a block using the
+// min/max luminance method, ensuring c0 > c1 so the four-color (opaque)
+// palette mode is always selected.
+func dxt1Endpoints(r, g, b [16]byte) (c0, c1 uint16) {
+	minIdx, maxIdx := 0, 0
+	minLuma, maxLuma := 1<<30, -1
+
+	for i := 0; i < 16; i++ {
+		luma := int(r[i])*299 + int(g[i])*587 + int(b[i])*114
+		if luma < minLuma {
+			minLuma = luma
+			minIdx = i
+		}
+		if luma > maxLuma {
+			maxLuma = luma
+			maxIdx = i
+		}
+	}
+
+	c0 = rgb565Pack(r[maxIdx], g[maxIdx], b[maxIdx])
+	c1 = rgb565Pack(r[minIdx], g[minIdx], b[minIdx])
+
+	if c0 < c1 {
+		c0, c1 = c1, c0
+	}
+	if c0 == c1 {
+		if c1 == 0 {
+			c0 = 1
+		} else {
+			c1--
+		}
+	}
+
+	return c0, c1
+}
@@ -0,0 +1,657 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+)
+
+func init() {
+	image.RegisterFormat("tpl", "\x00\x20\xAF\x30", Decode, DecodeConfig)
+}
+
+// ErrInvalidMagic is returned when a reader does not begin with TPLMagic.
+var ErrInvalidMagic = errors.New("tpl: invalid magic")
+
+// FromTPL reads every image stored in a TPL container, in the order they
+// appear in the file's image table.
+func FromTPL(r io.Reader) ([]image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var header FileHeader
+	if err := binary.Read(bytes.NewReader(data), binary.BigEndian, &header); err != nil {
+		return nil, err
+	}
+	if header.Magic != TPLMagic {
+		return nil, ErrInvalidMagic
+	}
+
+	// Validate the whole image table fits in data before trusting
+	// NumOfImages for anything, including as a slice capacity hint: it's
+	// attacker-controlled for any reader fed through image.Decode, and an
+	// inflated value would otherwise drive an unbounded allocation.
+	tableEnd := uint64(header.ImageTableOff) + uint64(header.NumOfImages)*8
+	if tableEnd > uint64(len(data)) {
+		return nil, errors.New("tpl: image table out of bounds")
+	}
+
+	images := make([]image.Image, 0, header.NumOfImages)
+	for i := uint32(0); i < header.NumOfImages; i++ {
+		entryOff := header.ImageTableOff + i*8
+		if int(entryOff)+8 > len(data) {
+			return nil, fmt.Errorf("tpl: image table entry %d out of bounds", i)
+		}
+
+		imageOff := binary.BigEndian.Uint32(data[entryOff:])
+		paletteOff := binary.BigEndian.Uint32(data[entryOff+4:])
+
+		var ih ImageHeader
+		if int(imageOff)+36 > len(data) {
+			return nil, fmt.Errorf("tpl: image header %d out of bounds", i)
+		}
+		if err := binary.Read(bytes.NewReader(data[imageOff:]), binary.BigEndian, &ih); err != nil {
+			return nil, err
+		}
+
+		img, err := decodeImage(data, ih, paletteOff)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	return images, nil
+}
+
+// Decode implements image.Decode for TPL files, returning the first image
+// in the container.
+func Decode(r io.Reader) (image.Image, error) {
+	images, err := FromTPL(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(images) == 0 {
+		return nil, errors.New("tpl: file contains no images")
+	}
+
+	return images[0], nil
+}
+
+// DecodeConfig implements image.DecodeConfig for TPL files, reporting the
+// dimensions and color model of the first image in the container.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	var header FileHeader
+	if err := binary.Read(bytes.NewReader(data), binary.BigEndian, &header); err != nil {
+		return image.Config{}, err
+	}
+	if header.Magic != TPLMagic {
+		return image.Config{}, ErrInvalidMagic
+	}
+	if header.NumOfImages == 0 {
+		return image.Config{}, errors.New("tpl: file contains no images")
+	}
+
+	entryOff := header.ImageTableOff
+	if int(entryOff)+8 > len(data) {
+		return image.Config{}, errors.New("tpl: image table entry out of bounds")
+	}
+	imageOff := binary.BigEndian.Uint32(data[entryOff:])
+	paletteOff := binary.BigEndian.Uint32(data[entryOff+4:])
+
+	var ih ImageHeader
+	if int(imageOff)+36 > len(data) {
+		return image.Config{}, errors.New("tpl: image header out of bounds")
+	}
+	if err := binary.Read(bytes.NewReader(data[imageOff:]), binary.BigEndian, &ih); err != nil {
+		return image.Config{}, err
+	}
+
+	switch TextureFormat(ih.Format) {
+	case I4, I8:
+		return image.Config{ColorModel: color.GrayModel, Width: int(ih.Width), Height: int(ih.Height)}, nil
+	case CI4, CI8:
+		pal, err := decodePalette(data, paletteOff)
+		if err != nil {
+			return image.Config{}, err
+		}
+		return image.Config{ColorModel: pal, Width: int(ih.Width), Height: int(ih.Height)}, nil
+	}
+
+	return image.Config{ColorModel: color.NRGBAModel, Width: int(ih.Width), Height: int(ih.Height)}, nil
+}
+
+// DecodeMipChain decodes the full mip chain produced by EncodeWithOptions
+// with GenerateMipmaps set: the base level described by ih, followed by
+// ih.MaxLOD additional levels, each half the size of the last. TPL mip
+// chains have no per-level offset table; each level's position is derived
+// by walking forward from ih.DataOffset using the same tiled-size formula
+// EncodeWithOptions used to lay them out.
+func DecodeMipChain(data []byte, ih ImageHeader) ([]image.Image, error) {
+	format := TextureFormat(ih.Format)
+	width, height := int(ih.Width), int(ih.Height)
+	offset := ih.DataOffset
+
+	images := make([]image.Image, 0, int(ih.MaxLOD)+1)
+	for level := 0; level <= int(ih.MaxLOD); level++ {
+		size, err := levelPixelSize(format, width, height)
+		if err != nil {
+			return nil, err
+		}
+		if int(offset)+size > len(data) {
+			return nil, fmt.Errorf("tpl: mip level %d out of bounds", level)
+		}
+
+		levelHeader := ImageHeader{Width: uint16(width), Height: uint16(height), Format: ih.Format, DataOffset: offset}
+		img, err := decodeImage(data, levelHeader, 0)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+
+		offset += uint32(size)
+		width = max(width/2, 1)
+		height = max(height/2, 1)
+	}
+
+	return images, nil
+}
+
+// decodeImage dispatches to the per-format decoder for ih, inverting the
+// tile ordering used by the matching ToXXX encoder.
+func decodeImage(data []byte, ih ImageHeader, paletteOff uint32) (image.Image, error) {
+	width := int(ih.Width)
+	height := int(ih.Height)
+	if int(ih.DataOffset) > len(data) {
+		return nil, errors.New("tpl: pixel data offset out of bounds")
+	}
+	pix := data[ih.DataOffset:]
+
+	switch TextureFormat(ih.Format) {
+	case I4:
+		return decodeI4(pix, width, height), nil
+	case I8:
+		return decodeI8(pix, width, height), nil
+	case IA4:
+		return decodeIA4(pix, width, height), nil
+	case IA8:
+		return decodeIA8(pix, width, height), nil
+	case RGB565:
+		return decodeRGB565(pix, width, height), nil
+	case RGB5A3:
+		return decodeRGB5A3(pix, width, height), nil
+	case RGBA8:
+		return decodeRGBA8(pix, width, height), nil
+	case CI4:
+		return decodeCI4(pix, data, paletteOff, width, height)
+	case CI8:
+		return decodeCI8(pix, data, paletteOff, width, height)
+	case CI14X2:
+		return decodeCI14X2(pix, data, paletteOff, width, height)
+	case CMP:
+		return decodeCMP(pix, width, height), nil
+	default:
+		return nil, fmt.Errorf("tpl: unsupported texture format %d", ih.Format)
+	}
+}
+
+// decodePalette reads the TLUT pointed to by paletteOff and returns it as a
+// color.Palette, in the color format it was stored in.
+func decodePalette(data []byte, paletteOff uint32) (color.Palette, error) {
+	if int(paletteOff)+12 > len(data) {
+		return nil, errors.New("tpl: palette header out of bounds")
+	}
+
+	var ph PaletteHeader
+	if err := binary.Read(bytes.NewReader(data[paletteOff:]), binary.BigEndian, &ph); err != nil {
+		return nil, err
+	}
+	if int(ph.DataOffset) > len(data) {
+		return nil, errors.New("tpl: palette data offset out of bounds")
+	}
+
+	entries := data[ph.DataOffset:]
+	palette := make(color.Palette, ph.NumEntries)
+	for i := 0; i < int(ph.NumEntries); i++ {
+		if i*2+2 > len(entries) {
+			palette[i] = color.NRGBA{}
+			continue
+		}
+		raw := binary.BigEndian.Uint16(entries[i*2:])
+		switch TextureFormat(ph.Format) {
+		case RGB565:
+			palette[i] = rgb565ToNRGBA(raw)
+		case IA8:
+			palette[i] = ia8ToNRGBA(raw)
+		default:
+			palette[i] = rgb5a3ToNRGBA(raw)
+		}
+	}
+
+	return palette, nil
+}
+
+func decodeI4(pix []byte, width, height int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 8 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for y := y1; y < y1+8; y++ {
+				for x := x1; x < x1+8; x += 2 {
+					if inp >= len(pix) {
+						return img
+					}
+					b := pix[inp]
+					inp++
+
+					if x < width && y < height {
+						img.SetGray(x, y, color.Gray{Y: ((b >> 4) & 0xf) * 17})
+					}
+					if x+1 < width && y < height {
+						img.SetGray(x+1, y, color.Gray{Y: (b & 0xf) * 17})
+					}
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func decodeI8(pix []byte, width, height int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+8; x++ {
+					if inp >= len(pix) {
+						return img
+					}
+					b := pix[inp]
+					inp++
+
+					if x < width && y < height {
+						img.SetGray(x, y, color.Gray{Y: b})
+					}
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func decodeIA4(pix []byte, width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+8; x++ {
+					if inp >= len(pix) {
+						return img
+					}
+					b := pix[inp]
+					inp++
+
+					if x < width && y < height {
+						i := (b & 0xf) * 17
+						a := ((b >> 4) & 0xf) * 17
+						img.SetNRGBA(x, y, color.NRGBA{R: i, G: i, B: i, A: a})
+					}
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func decodeIA8(pix []byte, width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 4 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+4; x++ {
+					if inp+2 > len(pix) {
+						return img
+					}
+					a := pix[inp]
+					i := pix[inp+1]
+					inp += 2
+
+					if x < width && y < height {
+						img.SetNRGBA(x, y, color.NRGBA{R: i, G: i, B: i, A: a})
+					}
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func decodeRGB565(pix []byte, width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 4 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+4; x++ {
+					if inp+2 > len(pix) {
+						return img
+					}
+					raw := binary.BigEndian.Uint16(pix[inp:])
+					inp += 2
+
+					if x < width && y < height {
+						img.SetNRGBA(x, y, rgb565ToNRGBA(raw))
+					}
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func decodeRGB5A3(pix []byte, width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 4 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+4; x++ {
+					if inp+2 > len(pix) {
+						return img
+					}
+					raw := binary.BigEndian.Uint16(pix[inp:])
+					inp += 2
+
+					if x < width && y < height {
+						img.SetNRGBA(x, y, rgb5a3ToNRGBA(raw))
+					}
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func decodeRGBA8(pix []byte, width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 4 {
+			if inp+64 > len(pix) {
+				return img
+			}
+
+			arTile := pix[inp : inp+32]
+			gbTile := pix[inp+32 : inp+64]
+			inp += 64
+
+			i := 0
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+4; x++ {
+					a := arTile[i*2]
+					r := arTile[i*2+1]
+					g := gbTile[i*2]
+					b := gbTile[i*2+1]
+					i++
+
+					if x < width && y < height {
+						img.SetNRGBA(x, y, color.NRGBA{R: r, G: g, B: b, A: a})
+					}
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func decodeCI4(pix, data []byte, paletteOff uint32, width, height int) (image.Image, error) {
+	palette, err := decodePalette(data, paletteOff)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 8 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for y := y1; y < y1+8; y++ {
+				for x := x1; x < x1+8; x += 2 {
+					if inp >= len(pix) {
+						return img, nil
+					}
+					b := pix[inp]
+					inp++
+
+					if x < width && y < height {
+						img.SetColorIndex(x, y, (b>>4)&0xf)
+					}
+					if x+1 < width && y < height {
+						img.SetColorIndex(x+1, y, b&0xf)
+					}
+				}
+			}
+		}
+	}
+
+	return img, nil
+}
+
+func decodeCI8(pix, data []byte, paletteOff uint32, width, height int) (image.Image, error) {
+	palette, err := decodePalette(data, paletteOff)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+8; x++ {
+					if inp >= len(pix) {
+						return img, nil
+					}
+					b := pix[inp]
+					inp++
+
+					if x < width && y < height {
+						img.SetColorIndex(x, y, b)
+					}
+				}
+			}
+		}
+	}
+
+	return img, nil
+}
+
+func decodeCI14X2(pix, data []byte, paletteOff uint32, width, height int) (image.Image, error) {
+	palette, err := decodePalette(data, paletteOff)
+	if err != nil {
+		return nil, err
+	}
+
+	// CI14X2 indices don't fit in image.Paletted's 8-bit index, so the
+	// decoded palette is expanded into direct colors instead.
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 4 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+4; x++ {
+					if inp+2 > len(pix) {
+						return img, nil
+					}
+					raw := binary.BigEndian.Uint16(pix[inp:])
+					inp += 2
+
+					if x < width && y < height {
+						index := int(raw & 0x3FFF)
+						if index < len(palette) {
+							img.SetNRGBA(x, y, palette[index].(color.NRGBA))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return img, nil
+}
+
+func decodeCMP(pix []byte, width, height int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 8 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for by := 0; by < 2; by++ {
+				for bx := 0; bx < 2; bx++ {
+					if inp+8 > len(pix) {
+						return img
+					}
+					decodeDXT1Block(pix[inp:inp+8], img, x1+bx*4, y1+by*4, width, height)
+					inp += 8
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// decodeDXT1Block decodes a single big-endian DXT1/BC1 block into img,
+// anchored at (ox, oy).
+func decodeDXT1Block(block []byte, img *image.NRGBA, ox, oy, width, height int) {
+	c0 := binary.BigEndian.Uint16(block[0:])
+	c1 := binary.BigEndian.Uint16(block[2:])
+	indices := binary.BigEndian.Uint32(block[4:])
+
+	palette := dxt1Palette(c0, c1)
+
+	for i := 0; i < 16; i++ {
+		x := ox + i%4
+		y := oy + i/4
+		if x >= width || y >= height {
+			continue
+		}
+
+		shift := uint(30 - i*2)
+		sel := (indices >> shift) & 0x3
+		img.SetNRGBA(x, y, palette[sel])
+	}
+}
+
+// dxt1Palette builds the four interpolated colors of a DXT1 block from its
+// two RGB565 endpoints, matching the encoder's four-color palette.
+func dxt1Palette(c0, c1 uint16) [4]color.NRGBA {
+	rgb0 := rgb565ToNRGBA(c0)
+	rgb1 := rgb565ToNRGBA(c1)
+
+	var palette [4]color.NRGBA
+	palette[0] = rgb0
+	palette[1] = rgb1
+
+	if c0 > c1 {
+		palette[2] = color.NRGBA{
+			R: uint8((2*int(rgb0.R) + int(rgb1.R)) / 3),
+			G: uint8((2*int(rgb0.G) + int(rgb1.G)) / 3),
+			B: uint8((2*int(rgb0.B) + int(rgb1.B)) / 3),
+			A: 0xff,
+		}
+		palette[3] = color.NRGBA{
+			R: uint8((int(rgb0.R) + 2*int(rgb1.R)) / 3),
+			G: uint8((int(rgb0.G) + 2*int(rgb1.G)) / 3),
+			B: uint8((int(rgb0.B) + 2*int(rgb1.B)) / 3),
+			A: 0xff,
+		}
+	} else {
+		palette[2] = color.NRGBA{
+			R: uint8((int(rgb0.R) + int(rgb1.R)) / 2),
+			G: uint8((int(rgb0.G) + int(rgb1.G)) / 2),
+			B: uint8((int(rgb0.B) + int(rgb1.B)) / 2),
+			A: 0xff,
+		}
+		palette[3] = color.NRGBA{}
+	}
+
+	return palette
+}
+
+func rgb565ToNRGBA(raw uint16) color.NRGBA {
+	r := uint8((raw >> 11) & 0x1f)
+	g := uint8((raw >> 5) & 0x3f)
+	b := uint8(raw & 0x1f)
+
+	return color.NRGBA{
+		R: (r << 3) | (r >> 2),
+		G: (g << 2) | (g >> 4),
+		B: (b << 3) | (b >> 2),
+		A: 0xff,
+	}
+}
+
+func ia8ToNRGBA(raw uint16) color.NRGBA {
+	a := byte(raw >> 8)
+	i := byte(raw)
+
+	return color.NRGBA{R: i, G: i, B: i, A: a}
+}
+
+func rgb5a3ToNRGBA(raw uint16) color.NRGBA {
+	if raw&0x8000 != 0 {
+		r := uint8((raw >> 10) & 0x1f)
+		g := uint8((raw >> 5) & 0x1f)
+		b := uint8(raw & 0x1f)
+
+		return color.NRGBA{
+			R: (r << 3) | (r >> 2),
+			G: (g << 3) | (g >> 2),
+			B: (b << 3) | (b >> 2),
+			A: 0xff,
+		}
+	}
+
+	a := uint8((raw >> 12) & 0x7)
+	r := uint8((raw >> 8) & 0xf)
+	g := uint8((raw >> 4) & 0xf)
+	b := uint8(raw & 0xf)
+
+	return color.NRGBA{
+		R: r * 17,
+		G: g * 17,
+		B: b * 17,
+		A: a * 36,
+	}
+}
@@ -0,0 +1,152 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// grayGradient builds a width x height grayscale image whose values cycle
+// through every 4-bit-representable level (multiples of 17), so formats
+// that quantize to 4 bits per channel round-trip exactly.
+func grayGradient(width, height int) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetGray(x, y, color.Gray{Y: byte((x+y)%16) * 17})
+		}
+	}
+
+	return img
+}
+
+func TestRoundTripI4(t *testing.T) {
+	src := grayGradient(8, 8)
+
+	data, err := Encode(src, I4)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	images, err := FromTPL(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("FromTPL: %v", err)
+	}
+	if len(images) != 1 {
+		t.Fatalf("got %d images, want 1", len(images))
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := src.GrayAt(x, y).Y
+			got := color.GrayModel.Convert(images[0].At(x, y)).(color.Gray).Y
+			if got != want {
+				t.Fatalf("pixel (%d,%d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+// TestRoundTripIA4 keeps alpha at full opacity, since imageToRGBA reads
+// back premultiplied RGBA() values and only recovers the original 8-bit
+// channel exactly when alpha is 255; this test is about IA4's tiling, not
+// about alpha-blending precision.
+func TestRoundTripIA4(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			v := byte((x+y)%16) * 17
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	data, err := Encode(img, IA4)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 8; x++ {
+			want := img.NRGBAAt(x, y)
+			got := decoded.At(x, y).(color.NRGBA)
+			if got != want {
+				t.Fatalf("pixel (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// TestRoundTripRGB565 uses only white, black and pure green, whose channels
+// are all 0 or 255: the two values that survive RGB565's 5/6-bit
+// quantize-then-expand round trip exactly.
+func TestRoundTripRGB565(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 255, G: 255, B: 255, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 0, G: 0, B: 0, A: 255})
+	img.SetNRGBA(2, 1, color.NRGBA{R: 0, G: 255, B: 0, A: 255})
+
+	data, err := Encode(img, RGB565)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	cases := []struct{ x, y int }{{0, 0}, {1, 0}, {2, 1}}
+	for _, c := range cases {
+		want := img.NRGBAAt(c.x, c.y)
+		got := decoded.At(c.x, c.y).(color.NRGBA)
+		if got != want {
+			t.Fatalf("pixel (%d,%d) = %+v, want %+v", c.x, c.y, got, want)
+		}
+	}
+}
+
+// TestFromTPLRejectsInflatedNumOfImages guards against a corrupt or
+// malicious NumOfImages driving an unbounded allocation before the image
+// table is validated to actually fit in the file.
+func TestFromTPLRejectsInflatedNumOfImages(t *testing.T) {
+	data := make([]byte, 12)
+	binary.BigEndian.PutUint32(data[0:], TPLMagic)
+	binary.BigEndian.PutUint32(data[4:], 0xFFFFFFF0)
+	binary.BigEndian.PutUint32(data[8:], 0x0C)
+
+	if _, err := FromTPL(bytes.NewReader(data)); err == nil {
+		t.Fatal("FromTPL: expected an error for an out-of-bounds image table, got nil")
+	}
+}
+
+func TestDecodeRegisteredWithImagePackage(t *testing.T) {
+	src := grayGradient(8, 8)
+
+	data, err := Encode(src, I4)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	_, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("image.Decode: %v", err)
+	}
+	if format != "tpl" {
+		t.Fatalf("format = %q, want tpl", format)
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("image.DecodeConfig: %v", err)
+	}
+	if cfg.Width != 8 || cfg.Height != 8 {
+		t.Fatalf("config = %dx%d, want 8x8", cfg.Width, cfg.Height)
+	}
+}
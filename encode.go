@@ -0,0 +1,161 @@
+package tpl
+
+import (
+	"fmt"
+	"image"
+)
+
+// Encode converts img to the given TextureFormat and wraps it in a TPL
+// file, dispatching to the matching ToXXX encoder. CI4, CI8 and CI14X2 are
+// not dispatched and return an unsupported-format error; they are encoded
+// through ToCI4, ToCI8 and ToCI14X2, which take a TLUT format.
+func Encode(img image.Image, format TextureFormat) ([]byte, error) {
+	switch format {
+	case I4:
+		return ToI4(img)
+	case I8:
+		return ToI8(img)
+	case IA4:
+		return ToIA4(img)
+	case IA8:
+		return ToIA8(img)
+	case RGB565:
+		return ToRGB565(img)
+	case RGB5A3:
+		return ToRGB5A3(img)
+	case RGBA8:
+		return ToRGBA8(img)
+	case CMP:
+		return ToCMP(img)
+	default:
+		return nil, fmt.Errorf("tpl: unsupported texture format %d", format)
+	}
+}
+
+// ToI8 converts an image.Image to I8 TPL format
+func ToI8(img image.Image) ([]byte, error) {
+	raw := imageToRGBA(img)
+
+	width := img.Bounds().Max.X
+	height := img.Bounds().Max.Y
+	inp := 0
+	output := make([]byte, addPadding(width, 8)*addPadding(height, 4))
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+8; x++ {
+					var newPixel byte
+
+					if x >= width || y >= height {
+						newPixel = 0
+					} else {
+						rgba := raw[x+(y*width)]
+
+						r := (rgba >> 16) & 0xff
+						g := (rgba >> 8) & 0xff
+						b := (rgba >> 0) & 0xff
+
+						newPixel = byte((r + g + b) / 3)
+					}
+
+					output[inp] = newPixel
+					inp++
+				}
+			}
+		}
+	}
+
+	return makeTPLHeader(output, I8, width, height)
+}
+
+// ToIA8 converts an image.Image to IA8 TPL format
+func ToIA8(img image.Image) ([]byte, error) {
+	raw := imageToRGBA(img)
+
+	width := img.Bounds().Max.X
+	height := img.Bounds().Max.Y
+	z := -1
+	output := make([]byte, addPadding(width, 4)*addPadding(height, 4)*2)
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 4 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+4; x++ {
+					var a, i byte
+
+					if x >= width || y >= height {
+						a, i = 0, 0
+					} else {
+						rgba := raw[x+(y*width)]
+
+						r := (rgba >> 16) & 0xff
+						g := (rgba >> 8) & 0xff
+						b := (rgba >> 0) & 0xff
+						a = byte((rgba >> 24) & 0xff)
+
+						i = byte((r + g + b) / 3)
+					}
+
+					z++
+					output[z] = a
+					z++
+					output[z] = i
+				}
+			}
+		}
+	}
+
+	return makeTPLHeader(output, IA8, width, height)
+}
+
+// ToRGBA8 converts an image.Image to RGBA8 TPL format
+func ToRGBA8(img image.Image) ([]byte, error) {
+	raw := imageToRGBA(img)
+
+	width := img.Bounds().Max.X
+	height := img.Bounds().Max.Y
+	output := make([]byte, addPadding(width, 4)*addPadding(height, 4)*4)
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 4 {
+			arTile := output[inp : inp+32]
+			gbTile := output[inp+32 : inp+64]
+			inp += 64
+
+			i := 0
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+4; x++ {
+					if x < width && y < height {
+						rgba := raw[x+(y*width)]
+
+						arTile[i*2] = byte((rgba >> 24) & 0xff)
+						arTile[i*2+1] = byte((rgba >> 16) & 0xff)
+						gbTile[i*2] = byte((rgba >> 8) & 0xff)
+						gbTile[i*2+1] = byte((rgba >> 0) & 0xff)
+					}
+					i++
+				}
+			}
+		}
+	}
+
+	return makeTPLHeader(output, RGBA8, width, height)
+}
+
+// rgbaAt reads the RGBA8888 value of raw at (x, y), returning transparent
+// black for coordinates outside width/height.
+func rgbaAt(raw []uint32, width, height, x, y int) (r, g, b, a byte) {
+	if x >= width || y >= height {
+		return 0, 0, 0, 0
+	}
+
+	rgba := raw[x+(y*width)]
+	return byte((rgba >> 16) & 0xff), byte((rgba >> 8) & 0xff), byte(rgba & 0xff), byte((rgba >> 24) & 0xff)
+}
+
+// rgb565Pack packs 8-bit color channels into an RGB565 word.
+func rgb565Pack(r, g, b byte) uint16 {
+	return uint16((uint16(r)>>3)<<11 | (uint16(g)>>2)<<5 | (uint16(b) >> 3))
+}
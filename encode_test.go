@@ -0,0 +1,124 @@
+package tpl
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRoundTripI8(t *testing.T) {
+	img := grayGradient(8, 8)
+
+	data, err := Encode(img, I8)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := img.GrayAt(x, y).Y
+			got := color.GrayModel.Convert(decoded.At(x, y)).(color.Gray).Y
+			if got != want {
+				t.Fatalf("pixel (%d,%d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRoundTripIA8(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			v := byte((x+y)%16) * 17
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	data, err := Encode(img, IA8)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := img.NRGBAAt(x, y)
+			got := decoded.At(x, y).(color.NRGBA)
+			if got != want {
+				t.Fatalf("pixel (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRoundTripRGBA8(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	img.SetNRGBA(0, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	img.SetNRGBA(1, 0, color.NRGBA{R: 200, G: 150, B: 100, A: 255})
+	img.SetNRGBA(3, 3, color.NRGBA{R: 255, G: 0, B: 255, A: 255})
+
+	data, err := Encode(img, RGBA8)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	cases := []struct{ x, y int }{{0, 0}, {1, 0}, {3, 3}}
+	for _, c := range cases {
+		want := img.NRGBAAt(c.x, c.y)
+		got := decoded.At(c.x, c.y).(color.NRGBA)
+		if got != want {
+			t.Fatalf("pixel (%d,%d) = %+v, want %+v", c.x, c.y, got, want)
+		}
+	}
+}
+
+// TestRoundTripCMP uses a block split between pure black and pure white,
+// the two values DXT1's interpolated palette reproduces exactly, so the
+// test can assert exact pixels rather than an error tolerance.
+func TestRoundTripCMP(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			c := color.NRGBA{A: 255}
+			if y < 2 {
+				c.R, c.G, c.B = 255, 255, 255
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+
+	data, err := Encode(img, CMP)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := img.NRGBAAt(x, y)
+			got := decoded.At(x, y).(color.NRGBA)
+			if got != want {
+				t.Fatalf("pixel (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package tpl
+
+import (
+	"image"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+// EncodeReader decodes the image in r, applies its EXIF orientation when
+// opts.AutoOrient is set, and encodes the result to format. It saves
+// callers from wiring up image decoding and EXIF parsing themselves before
+// handing a source JPEG/PNG/TIFF off to this package.
+func EncodeReader(r io.Reader, format TextureFormat, opts EncodeOptions) ([]byte, error) {
+	img, err := decodeOriented(r, opts.AutoOrient)
+	if err != nil {
+		return nil, err
+	}
+
+	return EncodeWithOptions(img, format, opts)
+}
+
+// decodeOriented decodes r, applying the source's EXIF orientation tag
+// (values 1-8) as a flip/rotate composition when autoOrient is true.
+func decodeOriented(r io.Reader, autoOrient bool) (image.Image, error) {
+	return imaging.Decode(r, imaging.AutoOrientation(autoOrient))
+}
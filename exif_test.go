@@ -0,0 +1,113 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// jpegOrientation wraps jpegData in a minimal EXIF APP1 segment declaring
+// the given orientation tag value, matching the structure imaging's
+// decoder looks for.
+func jpegOrientation(t *testing.T, jpegData []byte, orientation uint16) []byte {
+	t.Helper()
+
+	var exif bytes.Buffer
+	exif.WriteString("Exif\x00\x00")
+	exif.WriteString("II")
+	binary.Write(&exif, binary.LittleEndian, uint16(0x002A))
+	binary.Write(&exif, binary.LittleEndian, uint32(8))
+	binary.Write(&exif, binary.LittleEndian, uint16(1))
+	binary.Write(&exif, binary.LittleEndian, uint16(0x0112))
+	binary.Write(&exif, binary.LittleEndian, uint16(3))
+	binary.Write(&exif, binary.LittleEndian, uint32(1))
+	var value [4]byte
+	binary.LittleEndian.PutUint16(value[:2], orientation)
+	exif.Write(value[:])
+	binary.Write(&exif, binary.LittleEndian, uint32(0))
+
+	var out bytes.Buffer
+	out.Write(jpegData[:2]) // SOI
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(2+exif.Len()))
+	out.Write(exif.Bytes())
+	out.Write(jpegData[2:])
+
+	return out.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("jpeg.Encode: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestEncodeReaderAppliesEXIFOrientation(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			src.SetGray(x, y, color.Gray{Y: byte((x+y)%16) * 17})
+		}
+	}
+	jpegData := encodeJPEG(t, src)
+	oriented := jpegOrientation(t, jpegData, 6) // EXIF orientation 6: rotate 270
+	opts := EncodeOptions{AutoOrient: true, MinFilter: 1, MagFilter: 1}
+
+	got, err := EncodeReader(bytes.NewReader(oriented), I8, opts)
+	if err != nil {
+		t.Fatalf("EncodeReader: %v", err)
+	}
+
+	decodedSrc, err := jpeg.Decode(bytes.NewReader(jpegData))
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	want, err := EncodeWithOptions(imaging.Rotate270(decodedSrc), I8, opts)
+	if err != nil {
+		t.Fatalf("EncodeWithOptions: %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("EncodeReader with AutoOrient did not apply the EXIF rotation")
+	}
+}
+
+func TestEncodeReaderWithoutAutoOrientIgnoresEXIF(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			src.SetGray(x, y, color.Gray{Y: byte((x+y)%16) * 17})
+		}
+	}
+	jpegData := encodeJPEG(t, src)
+	oriented := jpegOrientation(t, jpegData, 6)
+	opts := EncodeOptions{AutoOrient: false, MinFilter: 1, MagFilter: 1}
+
+	got, err := EncodeReader(bytes.NewReader(oriented), I8, opts)
+	if err != nil {
+		t.Fatalf("EncodeReader: %v", err)
+	}
+
+	decodedSrc, err := jpeg.Decode(bytes.NewReader(jpegData))
+	if err != nil {
+		t.Fatalf("jpeg.Decode: %v", err)
+	}
+	want, err := EncodeWithOptions(decodedSrc, I8, opts)
+	if err != nil {
+		t.Fatalf("EncodeWithOptions: %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("EncodeReader with AutoOrient disabled should leave the image untouched")
+	}
+}
@@ -0,0 +1,164 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+
+	"github.com/disintegration/imaging"
+)
+
+// EncodeOptions configures sampler state and mipmap generation for
+// EncodeWithOptions.
+type EncodeOptions struct {
+	WrapS, WrapT         uint32
+	MinFilter, MagFilter uint32
+	LODBias              float32
+
+	// GenerateMipmaps builds a full mip chain, halving the image down to
+	// 1x1 with a Lanczos resample.
+	GenerateMipmaps bool
+	// MaxLevels caps the number of additional mip levels generated. Zero
+	// or negative means no cap (generate down to 1x1).
+	MaxLevels int
+
+	// AutoOrient applies the source image's EXIF orientation before
+	// encoding, when decoding through EncodeReader. Use
+	// DefaultEncodeOptions to get this turned on, since the zero value of
+	// EncodeOptions leaves it off like every other field here.
+	AutoOrient bool
+}
+
+// DefaultEncodeOptions returns the EncodeOptions a caller would reach for
+// without a specific reason to change anything: MinFilter and MagFilter set
+// to 1 (linear), as makeTPLHeader writes them, and EXIF auto-orientation
+// turned on.
+func DefaultEncodeOptions() EncodeOptions {
+	return EncodeOptions{
+		MinFilter:  1,
+		MagFilter:  1,
+		AutoOrient: true,
+	}
+}
+
+// EncodeWithOptions converts img to format like Encode, but additionally
+// honors sampler settings and generates a mipmap chain when requested. The
+// chain is tiled per level and concatenated after the base level; MaxLOD
+// is set so a reader can tell how many levels follow, and DecodeMipChain
+// can walk the result back into one image.Image per level.
+//
+// CI4/CI8/CI14X2 aren't supported here since they need a TLUT per call;
+// use ToCI4/ToCI8/ToCI14X2 directly for palettized textures.
+func EncodeWithOptions(img image.Image, format TextureFormat, opts EncodeOptions) ([]byte, error) {
+	width := img.Bounds().Max.X
+	height := img.Bounds().Max.Y
+
+	levels := []image.Image{img}
+	if opts.GenerateMipmaps {
+		levels = append(levels, buildMipChain(img, opts.MaxLevels)...)
+	}
+
+	payload := bytes.NewBuffer(nil)
+	for _, level := range levels {
+		raw, err := tileLevel(level, format)
+		if err != nil {
+			return nil, err
+		}
+		payload.Write(raw)
+	}
+
+	return makeTPLHeaderWithOptions(payload.Bytes(), format, width, height, opts, uint8(len(levels)-1))
+}
+
+// buildMipChain successively halves img with a Lanczos resample until it
+// reaches 1x1, or until maxLevels additional levels have been produced.
+func buildMipChain(img image.Image, maxLevels int) []image.Image {
+	var levels []image.Image
+
+	width := img.Bounds().Max.X
+	height := img.Bounds().Max.Y
+	current := img
+
+	for width > 1 || height > 1 {
+		if maxLevels > 0 && len(levels) >= maxLevels {
+			break
+		}
+
+		width = max(width/2, 1)
+		height = max(height/2, 1)
+		current = imaging.Resize(current, width, height, imaging.Lanczos)
+		levels = append(levels, current)
+	}
+
+	return levels
+}
+
+// tileLevel encodes level and strips the file/image header, returning just
+// its tiled pixel payload so multiple levels can be concatenated.
+func tileLevel(level image.Image, format TextureFormat) ([]byte, error) {
+	encoded, err := Encode(level, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return encoded[nonIndexedHeaderSize:], nil
+}
+
+// levelPixelSize returns the number of tiled pixel-data bytes a single mip
+// level of format takes up at the given dimensions, matching the ToXXX
+// encoders' own padded tile math. TPL mip chains carry no per-level offset
+// table of their own, so DecodeMipChain walks the chain by recomputing
+// each level's size with this instead.
+func levelPixelSize(format TextureFormat, width, height int) (int, error) {
+	switch format {
+	case I4:
+		return addPadding(width, 8) * addPadding(height, 8) / 2, nil
+	case I8, IA4:
+		return addPadding(width, 8) * addPadding(height, 4), nil
+	case IA8, RGB565, RGB5A3:
+		return addPadding(width, 4) * addPadding(height, 4) * 2, nil
+	case RGBA8:
+		return addPadding(width, 4) * addPadding(height, 4) * 4, nil
+	case CMP:
+		return (addPadding(width, 8) / 8) * (addPadding(height, 8) / 8) * 32, nil
+	default:
+		return 0, fmt.Errorf("tpl: format %d does not support mipmaps", format)
+	}
+}
+
+// makeTPLHeaderWithOptions is like makeTPLHeader but threads sampler state
+// from opts and an explicit MaxLOD through to the ImageHeader.
+func makeTPLHeaderWithOptions(raw []byte, format TextureFormat, width, height int, opts EncodeOptions, maxLOD uint8) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+
+	tpl := TPL{
+		Header: FileHeader{
+			Magic:         TPLMagic,
+			NumOfImages:   1,
+			ImageTableOff: 0x0C,
+		},
+		ImageOff:   20,
+		PaletteOff: 0,
+		Image: ImageHeader{
+			Height:     uint16(height),
+			Width:      uint16(width),
+			Format:     uint32(format),
+			DataOffset: nonIndexedHeaderSize,
+			WrapS:      opts.WrapS,
+			WrapT:      opts.WrapT,
+			MinFilter:  opts.MinFilter,
+			MagFilter:  opts.MagFilter,
+			LODBias:    opts.LODBias,
+			MaxLOD:     maxLOD,
+		},
+	}
+
+	if err := binary.Write(buf, binary.BigEndian, tpl); err != nil {
+		return nil, err
+	}
+
+	buf.Write(raw)
+
+	return buf.Bytes(), nil
+}
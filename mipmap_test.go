@@ -0,0 +1,110 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeWithOptionsMipChain(t *testing.T) {
+	img := grayGradient(8, 8)
+
+	data, err := EncodeWithOptions(img, I8, EncodeOptions{GenerateMipmaps: true})
+	if err != nil {
+		t.Fatalf("EncodeWithOptions: %v", err)
+	}
+
+	var ih ImageHeader
+	if err := binary.Read(bytes.NewReader(data[20:]), binary.BigEndian, &ih); err != nil {
+		t.Fatalf("reading ImageHeader: %v", err)
+	}
+	if ih.MaxLOD != 3 {
+		t.Fatalf("MaxLOD = %d, want 3 (floor(log2(8)))", ih.MaxLOD)
+	}
+
+	levels, err := DecodeMipChain(data, ih)
+	if err != nil {
+		t.Fatalf("DecodeMipChain: %v", err)
+	}
+	if len(levels) != int(ih.MaxLOD)+1 {
+		t.Fatalf("got %d levels, want %d", len(levels), ih.MaxLOD+1)
+	}
+
+	wantSizes := []int{8, 4, 2, 1}
+	for i, level := range levels {
+		bounds := level.Bounds()
+		if bounds.Dx() != wantSizes[i] || bounds.Dy() != wantSizes[i] {
+			t.Fatalf("level %d size = %dx%d, want %dx%d", i, bounds.Dx(), bounds.Dy(), wantSizes[i], wantSizes[i])
+		}
+	}
+
+	base := levels[0]
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := img.GrayAt(x, y).Y
+			got := color.GrayModel.Convert(base.At(x, y)).(color.Gray).Y
+			if got != want {
+				t.Fatalf("base level pixel (%d,%d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+}
+
+// TestEncodeWithOptionsMaxLevelsCapsMaxLOD guards against MaxLOD being
+// re-derived from width/height independent of MaxLevels, which would
+// claim more levels than were actually written and make DecodeMipChain
+// walk past the end of the payload.
+func TestEncodeWithOptionsMaxLevelsCapsMaxLOD(t *testing.T) {
+	img := grayGradient(8, 8)
+
+	data, err := EncodeWithOptions(img, I8, EncodeOptions{GenerateMipmaps: true, MaxLevels: 1})
+	if err != nil {
+		t.Fatalf("EncodeWithOptions: %v", err)
+	}
+
+	var ih ImageHeader
+	if err := binary.Read(bytes.NewReader(data[20:]), binary.BigEndian, &ih); err != nil {
+		t.Fatalf("reading ImageHeader: %v", err)
+	}
+	if ih.MaxLOD != 1 {
+		t.Fatalf("MaxLOD = %d, want 1 (base level + 1 generated level)", ih.MaxLOD)
+	}
+
+	levels, err := DecodeMipChain(data, ih)
+	if err != nil {
+		t.Fatalf("DecodeMipChain: %v", err)
+	}
+	if len(levels) != 2 {
+		t.Fatalf("got %d levels, want 2", len(levels))
+	}
+}
+
+func TestEncodeWithOptionsNoMipmapsHasNoChain(t *testing.T) {
+	img := grayGradient(8, 8)
+
+	data, err := EncodeWithOptions(img, I8, EncodeOptions{})
+	if err != nil {
+		t.Fatalf("EncodeWithOptions: %v", err)
+	}
+
+	var ih ImageHeader
+	if err := binary.Read(bytes.NewReader(data[20:]), binary.BigEndian, &ih); err != nil {
+		t.Fatalf("reading ImageHeader: %v", err)
+	}
+	if ih.MaxLOD != 0 {
+		t.Fatalf("MaxLOD = %d, want 0", ih.MaxLOD)
+	}
+
+	levels, err := DecodeMipChain(data, ih)
+	if err != nil {
+		t.Fatalf("DecodeMipChain: %v", err)
+	}
+	if len(levels) != 1 {
+		t.Fatalf("got %d levels, want 1", len(levels))
+	}
+	if levels[0].Bounds() != image.Rect(0, 0, 8, 8) {
+		t.Fatalf("level 0 bounds = %v, want 8x8", levels[0].Bounds())
+	}
+}
@@ -0,0 +1,217 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// dataAlignment is the byte alignment TPL texture/TLUT payloads are padded
+// to within a multi-image container.
+const dataAlignment = 32
+
+// Entry describes one texture to pack into a multi-image TPL container via
+// EncodeMulti.
+type Entry struct {
+	Image   image.Image
+	Format  TextureFormat
+	Options EncodeOptions
+
+	// PaletteFormat selects the TLUT color format for CI4/CI8/CI14X2
+	// entries; it's ignored for every other format. Defaults to RGB565.
+	PaletteFormat TextureFormat
+}
+
+// builtEntry holds an Entry's encoded pieces before final offsets are
+// known. The PaletteHeader itself isn't serialized yet, since its
+// DataOffset depends on where EncodeMulti ends up placing paletteData.
+type builtEntry struct {
+	header        ImageHeader
+	palette       color.Palette
+	paletteFormat TextureFormat
+	paletteData   []byte
+	pixelData     []byte
+}
+
+// EncodeMulti packs several textures into a single TPL container, each
+// indexed through the image table at FileHeader.ImageTableOff. Every
+// entry's pixel and TLUT data is laid out with 32-byte alignment, and all
+// offsets are back-patched once the full layout is known.
+func EncodeMulti(entries []Entry) ([]byte, error) {
+	if len(entries) == 0 {
+		return nil, errors.New("tpl: EncodeMulti requires at least one entry")
+	}
+
+	built := make([]builtEntry, len(entries))
+	for i, e := range entries {
+		b, err := buildEntry(e)
+		if err != nil {
+			return nil, fmt.Errorf("tpl: entry %d: %w", i, err)
+		}
+		built[i] = b
+	}
+
+	const fileHeaderSize = 12
+	const tableEntrySize = 8
+	const imageHeaderSize = 36
+
+	tableOff := fileHeaderSize
+	headersOff := tableOff + len(entries)*tableEntrySize
+	cursor := headersOff + len(entries)*imageHeaderSize
+
+	imageOffs := make([]uint32, len(entries))
+	paletteOffs := make([]uint32, len(entries))
+
+	for i, b := range built {
+		imageOffs[i] = uint32(headersOff + i*imageHeaderSize)
+
+		if len(b.paletteData) > 0 {
+			paletteOff := alignUp(cursor, dataAlignment)
+			paletteOffs[i] = uint32(paletteOff)
+			cursor = paletteOff + paletteHeaderSize + len(b.paletteData)
+		}
+
+		dataOff := alignUp(cursor, dataAlignment)
+		built[i].header.DataOffset = uint32(dataOff)
+		cursor = dataOff + len(b.pixelData)
+	}
+
+	buf := bytes.NewBuffer(nil)
+
+	header := FileHeader{
+		Magic:         TPLMagic,
+		NumOfImages:   uint32(len(entries)),
+		ImageTableOff: uint32(tableOff),
+	}
+	if err := binary.Write(buf, binary.BigEndian, header); err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if err := binary.Write(buf, binary.BigEndian, imageOffs[i]); err != nil {
+			return nil, err
+		}
+		if err := binary.Write(buf, binary.BigEndian, paletteOffs[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, b := range built {
+		if err := binary.Write(buf, binary.BigEndian, b.header); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, b := range built {
+		if len(b.paletteData) > 0 {
+			padTo(buf, int(paletteOffs[i]))
+
+			ph := PaletteHeader{
+				NumEntries: uint16(len(b.palette)),
+				Format:     uint32(b.paletteFormat),
+				DataOffset: paletteOffs[i] + paletteHeaderSize,
+			}
+			if err := binary.Write(buf, binary.BigEndian, ph); err != nil {
+				return nil, err
+			}
+			buf.Write(b.paletteData)
+		}
+
+		padTo(buf, int(b.header.DataOffset))
+		buf.Write(b.pixelData)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// buildEntry encodes e's image and, for palettized formats, its TLUT,
+// without yet knowing their final file offsets.
+func buildEntry(e Entry) (builtEntry, error) {
+	width := e.Image.Bounds().Max.X
+	height := e.Image.Bounds().Max.Y
+
+	ih := ImageHeader{
+		Height:    uint16(height),
+		Width:     uint16(width),
+		Format:    uint32(e.Format),
+		WrapS:     e.Options.WrapS,
+		WrapT:     e.Options.WrapT,
+		MinFilter: e.Options.MinFilter,
+		MagFilter: e.Options.MagFilter,
+		LODBias:   e.Options.LODBias,
+	}
+
+	switch e.Format {
+	case CI4, CI8, CI14X2:
+		maxColors := map[TextureFormat]int{CI4: 16, CI8: 256, CI14X2: 256}[e.Format]
+
+		paletted, ok := e.Image.(*image.Paletted)
+		if !ok {
+			paletted = quantizeImage(e.Image, maxColors)
+		}
+		if len(paletted.Palette) > maxColors {
+			return builtEntry{}, fmt.Errorf("palette has %d colors, format %d allows at most %d", len(paletted.Palette), e.Format, maxColors)
+		}
+
+		var pixelData []byte
+		switch e.Format {
+		case CI4:
+			pixelData = tileCI4(paletted, width, height)
+		case CI8:
+			pixelData = tileCI8(paletted, width, height)
+		case CI14X2:
+			pixelData = tileCI14X2(paletted, width, height)
+		}
+
+		paletteFormat := e.PaletteFormat
+		if paletteFormat == 0 {
+			paletteFormat = RGB565
+		}
+
+		paletteData, err := encodeTLUT(paletted.Palette, paletteFormat)
+		if err != nil {
+			return builtEntry{}, err
+		}
+
+		return builtEntry{header: ih, palette: paletted.Palette, paletteFormat: paletteFormat, paletteData: paletteData, pixelData: pixelData}, nil
+
+	default:
+		levels := []image.Image{e.Image}
+		if e.Options.GenerateMipmaps {
+			levels = append(levels, buildMipChain(e.Image, e.Options.MaxLevels)...)
+		}
+
+		payload := bytes.NewBuffer(nil)
+		for _, level := range levels {
+			raw, err := tileLevel(level, e.Format)
+			if err != nil {
+				return builtEntry{}, err
+			}
+			payload.Write(raw)
+		}
+
+		ih.MaxLOD = uint8(len(levels) - 1)
+
+		return builtEntry{header: ih, pixelData: payload.Bytes()}, nil
+	}
+}
+
+// alignUp rounds n up to the next multiple of to.
+func alignUp(n, to int) int {
+	if n%to == 0 {
+		return n
+	}
+
+	return n + (to - n%to)
+}
+
+// padTo writes zero bytes to buf until its length reaches off. off must
+// not be smaller than buf's current length.
+func padTo(buf *bytes.Buffer, off int) {
+	if pad := off - buf.Len(); pad > 0 {
+		buf.Write(make([]byte, pad))
+	}
+}
@@ -0,0 +1,102 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeMultiRoundTrip(t *testing.T) {
+	gray := grayGradient(8, 8)
+
+	rgba := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	rgba.SetNRGBA(0, 0, color.NRGBA{R: 10, G: 20, B: 30, A: 255})
+	rgba.SetNRGBA(3, 3, color.NRGBA{R: 255, G: 0, B: 255, A: 255})
+
+	ci := palettedFixture()
+
+	entries := []Entry{
+		{Image: gray, Format: I8},
+		{Image: rgba, Format: RGBA8},
+		{Image: ci, Format: CI4, PaletteFormat: RGB565},
+	}
+
+	data, err := EncodeMulti(entries)
+	if err != nil {
+		t.Fatalf("EncodeMulti: %v", err)
+	}
+
+	images, err := FromTPL(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("FromTPL: %v", err)
+	}
+	if len(images) != len(entries) {
+		t.Fatalf("got %d images, want %d", len(images), len(entries))
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			want := gray.GrayAt(x, y).Y
+			got := color.GrayModel.Convert(images[0].At(x, y)).(color.Gray).Y
+			if got != want {
+				t.Fatalf("entry 0 pixel (%d,%d) = %d, want %d", x, y, got, want)
+			}
+		}
+	}
+
+	for _, c := range []struct{ x, y int }{{0, 0}, {3, 3}} {
+		want := rgba.NRGBAAt(c.x, c.y)
+		got := images[1].At(c.x, c.y).(color.NRGBA)
+		if got != want {
+			t.Fatalf("entry 1 pixel (%d,%d) = %+v, want %+v", c.x, c.y, got, want)
+		}
+	}
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := ci.At(x, y)
+			got := images[2].At(x, y)
+			wr, wg, wb, wa := want.RGBA()
+			gr, gg, gb, ga := got.RGBA()
+			if wr != gr || wg != gg || wb != gb || wa != ga {
+				t.Fatalf("entry 2 pixel (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
+
+// TestEncodeMultiMaxLevelsCapsMaxLOD guards against buildEntry re-deriving
+// MaxLOD from width/height independent of MaxLevels, which would claim more
+// levels than were actually written and make DecodeMipChain walk past the
+// end of the payload.
+func TestEncodeMultiMaxLevelsCapsMaxLOD(t *testing.T) {
+	img := grayGradient(8, 8)
+
+	entries := []Entry{
+		{Image: img, Format: I8, Options: EncodeOptions{GenerateMipmaps: true, MaxLevels: 1}},
+	}
+
+	data, err := EncodeMulti(entries)
+	if err != nil {
+		t.Fatalf("EncodeMulti: %v", err)
+	}
+
+	const headersOff = 12 + 1*8
+	var ih ImageHeader
+	if err := binary.Read(bytes.NewReader(data[headersOff:]), binary.BigEndian, &ih); err != nil {
+		t.Fatalf("reading ImageHeader: %v", err)
+	}
+	if ih.MaxLOD != 1 {
+		t.Fatalf("MaxLOD = %d, want 1 (base level + 1 generated level)", ih.MaxLOD)
+	}
+
+	levels, err := DecodeMipChain(data, ih)
+	if err != nil {
+		t.Fatalf("DecodeMipChain: %v", err)
+	}
+	if len(levels) != 2 {
+		t.Fatalf("got %d levels, want 2", len(levels))
+	}
+}
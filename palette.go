@@ -0,0 +1,235 @@
+package tpl
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// ToCI4 converts an image.Image to CI4 TPL format, palettizing it to 16
+// colors first if it isn't already an *image.Paletted. paletteFormat picks
+// the TLUT's color format (RGB565, RGB5A3, or IA8).
+func ToCI4(img image.Image, paletteFormat TextureFormat) ([]byte, error) {
+	return toIndexed(img, CI4, paletteFormat, 16)
+}
+
+// ToCI8 converts an image.Image to CI8 TPL format, palettizing it to 256
+// colors first if it isn't already an *image.Paletted. paletteFormat picks
+// the TLUT's color format (RGB565, RGB5A3, or IA8).
+func ToCI8(img image.Image, paletteFormat TextureFormat) ([]byte, error) {
+	return toIndexed(img, CI8, paletteFormat, 256)
+}
+
+// ToCI14X2 converts an image.Image to CI14X2 TPL format. CI14X2 indices are
+// 14 bits wide on real hardware, but since Go's image.Paletted only
+// addresses 256 colors, the palette is capped there just like CI8.
+func ToCI14X2(img image.Image, paletteFormat TextureFormat) ([]byte, error) {
+	return toIndexed(img, CI14X2, paletteFormat, 256)
+}
+
+// toIndexed quantizes img to maxColors (if it isn't already palettized),
+// tiles the indices per format, and emits a TPL file with a TLUT in
+// paletteFormat.
+func toIndexed(img image.Image, format, paletteFormat TextureFormat, maxColors int) ([]byte, error) {
+	paletted, ok := img.(*image.Paletted)
+	if !ok {
+		paletted = quantizeImage(img, maxColors)
+	}
+	if len(paletted.Palette) > maxColors {
+		return nil, fmt.Errorf("tpl: palette has %d colors, format %d allows at most %d", len(paletted.Palette), format, maxColors)
+	}
+
+	width := img.Bounds().Max.X
+	height := img.Bounds().Max.Y
+
+	var indexData []byte
+	switch format {
+	case CI4:
+		indexData = tileCI4(paletted, width, height)
+	case CI8:
+		indexData = tileCI8(paletted, width, height)
+	case CI14X2:
+		indexData = tileCI14X2(paletted, width, height)
+	default:
+		return nil, fmt.Errorf("tpl: %d is not a palettized format", format)
+	}
+
+	tlutData, err := encodeTLUT(paletted.Palette, paletteFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	return makeIndexedTPLHeader(indexData, tlutData, paletted.Palette, paletteFormat, format, width, height)
+}
+
+// colorIndexAt returns the palette index at (x, y), or 0 for coordinates
+// added as tile padding.
+func colorIndexAt(p *image.Paletted, x, y, width, height int) byte {
+	if x >= width || y >= height {
+		return 0
+	}
+
+	return p.ColorIndexAt(x, y)
+}
+
+// tileCI4 packs palette indices into 8x8 tiles, two 4-bit indices per byte.
+func tileCI4(p *image.Paletted, width, height int) []byte {
+	output := make([]byte, addPadding(width, 8)*addPadding(height, 8)/2)
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 8 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for y := y1; y < y1+8; y++ {
+				for x := x1; x < x1+8; x += 2 {
+					i1 := colorIndexAt(p, x, y, width, height) & 0xf
+					i2 := colorIndexAt(p, x+1, y, width, height) & 0xf
+
+					output[inp] = (i1 << 4) | i2
+					inp++
+				}
+			}
+		}
+	}
+
+	return output
+}
+
+// tileCI8 packs palette indices into 8x4 tiles, one 8-bit index per byte.
+func tileCI8(p *image.Paletted, width, height int) []byte {
+	output := make([]byte, addPadding(width, 8)*addPadding(height, 4))
+	inp := 0
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 8 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+8; x++ {
+					output[inp] = colorIndexAt(p, x, y, width, height)
+					inp++
+				}
+			}
+		}
+	}
+
+	return output
+}
+
+// tileCI14X2 packs palette indices into 4x4 tiles, one 16-bit index per
+// pixel with the top two bits masked off.
+func tileCI14X2(p *image.Paletted, width, height int) []byte {
+	output := make([]byte, addPadding(width, 4)*addPadding(height, 4)*2)
+	z := -1
+
+	for y1 := 0; y1 < height; y1 += 4 {
+		for x1 := 0; x1 < width; x1 += 4 {
+			for y := y1; y < y1+4; y++ {
+				for x := x1; x < x1+4; x++ {
+					index := uint16(colorIndexAt(p, x, y, width, height)) & 0x3FFF
+
+					z++
+					output[z] = byte(index >> 8)
+					z++
+					output[z] = byte(index & 0xff)
+				}
+			}
+		}
+	}
+
+	return output
+}
+
+// packRGB5A3 packs 8-bit color channels into an RGB5A3 word, matching
+// ToRGB5A3's threshold between the RGB5 and A3RGB4 encodings.
+func packRGB5A3(r, g, b, a byte) uint16 {
+	if a <= 0xda {
+		return uint16((uint16(a)>>5)<<12 | (uint16(r)>>4)<<8 | (uint16(g)>>4)<<4 | (uint16(b) >> 4))
+	}
+
+	return 1<<15 | (uint16(r)>>3)<<10 | (uint16(g)>>3)<<5 | (uint16(b) >> 3)
+}
+
+// packIA8 packs an intensity and alpha byte into an IA8 TLUT word.
+func packIA8(i, a byte) uint16 {
+	return uint16(a)<<8 | uint16(i)
+}
+
+// encodeTLUT serializes palette as TLUT entries in the given color format.
+// The caller is responsible for writing the PaletteHeader that precedes
+// this data, since its DataOffset depends on where the caller places it
+// within the file.
+func encodeTLUT(palette color.Palette, format TextureFormat) (data []byte, err error) {
+	if format != RGB565 && format != RGB5A3 && format != IA8 {
+		return nil, fmt.Errorf("tpl: %d is not a valid TLUT format", format)
+	}
+
+	data = make([]byte, len(palette)*2)
+	for i, c := range palette {
+		r, g, b, a := c.RGBA()
+		r8, g8, b8, a8 := byte(r>>8), byte(g>>8), byte(b>>8), byte(a>>8)
+
+		var word uint16
+		switch format {
+		case RGB565:
+			word = rgb565Pack(r8, g8, b8)
+		case RGB5A3:
+			word = packRGB5A3(r8, g8, b8, a8)
+		case IA8:
+			word = packIA8(byte((uint16(r8)+uint16(g8)+uint16(b8))/3), a8)
+		}
+
+		binary.BigEndian.PutUint16(data[i*2:], word)
+	}
+
+	return data, nil
+}
+
+// makeIndexedTPLHeader assembles a single-image TPL file with a palette:
+// file header, image header, TLUT, then tiled index data.
+func makeIndexedTPLHeader(indexData, tlutData []byte, palette color.Palette, paletteFormat, format TextureFormat, width, height int) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+
+	const fileHeaderSize = 12
+	const offsetTableSize = 8
+	const imageHeaderSize = 36
+
+	paletteOff := uint32(fileHeaderSize + offsetTableSize + imageHeaderSize)
+	tlutDataOff := paletteOff + paletteHeaderSize
+	dataOffset := tlutDataOff + uint32(len(tlutData))
+
+	tpl := TPL{
+		Header: FileHeader{
+			Magic:         TPLMagic,
+			NumOfImages:   1,
+			ImageTableOff: fileHeaderSize,
+		},
+		ImageOff:   fileHeaderSize + offsetTableSize,
+		PaletteOff: paletteOff,
+		Image: ImageHeader{
+			Height:     uint16(height),
+			Width:      uint16(width),
+			Format:     uint32(format),
+			DataOffset: dataOffset,
+			MinFilter:  1,
+			MagFilter:  1,
+		},
+	}
+
+	if err := binary.Write(buf, binary.BigEndian, tpl); err != nil {
+		return nil, err
+	}
+
+	ph := PaletteHeader{
+		NumEntries: uint16(len(palette)),
+		Format:     uint32(paletteFormat),
+		DataOffset: tlutDataOff,
+	}
+	if err := binary.Write(buf, binary.BigEndian, ph); err != nil {
+		return nil, err
+	}
+
+	buf.Write(tlutData)
+	buf.Write(indexData)
+
+	return buf.Bytes(), nil
+}
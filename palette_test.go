@@ -0,0 +1,70 @@
+package tpl
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func palettedFixture() *image.Paletted {
+	palette := color.Palette{
+		color.NRGBA{R: 0, G: 0, B: 0, A: 255},
+		color.NRGBA{R: 255, G: 255, B: 255, A: 255},
+		color.NRGBA{R: 255, G: 0, B: 0, A: 255},
+		color.NRGBA{R: 0, G: 255, B: 0, A: 255},
+	}
+
+	img := image.NewPaletted(image.Rect(0, 0, 4, 4), palette)
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetColorIndex(x, y, uint8((x+y)%len(palette)))
+		}
+	}
+
+	return img
+}
+
+func testPalettedRoundTrip(t *testing.T, encode func(*image.Paletted) ([]byte, error)) {
+	img := palettedFixture()
+
+	data, err := encode(img)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	decoded, err := Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			want := img.At(x, y)
+			got := decoded.At(x, y)
+			wr, wg, wb, wa := want.RGBA()
+			gr, gg, gb, ga := got.RGBA()
+			if wr != gr || wg != gg || wb != gb || wa != ga {
+				t.Fatalf("pixel (%d,%d) = %+v, want %+v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestRoundTripCI4(t *testing.T) {
+	testPalettedRoundTrip(t, func(img *image.Paletted) ([]byte, error) {
+		return ToCI4(img, RGB565)
+	})
+}
+
+func TestRoundTripCI8(t *testing.T) {
+	testPalettedRoundTrip(t, func(img *image.Paletted) ([]byte, error) {
+		return ToCI8(img, RGB5A3)
+	})
+}
+
+func TestRoundTripCI14X2(t *testing.T) {
+	testPalettedRoundTrip(t, func(img *image.Paletted) ([]byte, error) {
+		return ToCI14X2(img, RGB565)
+	})
+}
@@ -0,0 +1,130 @@
+package tpl
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sort"
+)
+
+// medianCutPalette builds a palette of at most numColors colors from img
+// using the median-cut algorithm: the color space is recursively split
+// along its widest channel until enough buckets exist, then each bucket is
+// averaged down to a single color.
+func medianCutPalette(img image.Image, numColors int) color.Palette {
+	bounds := img.Bounds()
+	pixels := make([]color.NRGBA, 0, bounds.Dx()*bounds.Dy())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			pixels = append(pixels, color.NRGBA{R: uint8(r >> 8), G: uint8(g >> 8), B: uint8(b >> 8), A: uint8(a >> 8)})
+		}
+	}
+
+	buckets := [][]color.NRGBA{pixels}
+	for len(buckets) < numColors {
+		splitIdx, splitChannel, widest := -1, 0, -1
+		for i, bucket := range buckets {
+			if len(bucket) < 2 {
+				continue
+			}
+			channel, rng := widestChannel(bucket)
+			if rng > widest {
+				splitIdx, splitChannel, widest = i, channel, rng
+			}
+		}
+		if splitIdx == -1 {
+			break
+		}
+
+		bucket := buckets[splitIdx]
+		sort.Slice(bucket, func(i, j int) bool {
+			return channelValue(bucket[i], splitChannel) < channelValue(bucket[j], splitChannel)
+		})
+
+		mid := len(bucket) / 2
+		buckets[splitIdx] = bucket[:mid]
+		buckets = append(buckets, bucket[mid:])
+	}
+
+	palette := make(color.Palette, len(buckets))
+	for i, bucket := range buckets {
+		palette[i] = averageColor(bucket)
+	}
+
+	return palette
+}
+
+// widestChannel reports which of R/G/B/A has the largest value range in
+// bucket, and how wide that range is.
+func widestChannel(bucket []color.NRGBA) (channel, rng int) {
+	var lo, hi [4]uint8
+	lo = [4]uint8{255, 255, 255, 255}
+
+	for _, c := range bucket {
+		v := [4]uint8{c.R, c.G, c.B, c.A}
+		for i := 0; i < 4; i++ {
+			if v[i] < lo[i] {
+				lo[i] = v[i]
+			}
+			if v[i] > hi[i] {
+				hi[i] = v[i]
+			}
+		}
+	}
+
+	widest, widestRange := 0, -1
+	for i := 0; i < 4; i++ {
+		r := int(hi[i]) - int(lo[i])
+		if r > widestRange {
+			widest, widestRange = i, r
+		}
+	}
+
+	return widest, widestRange
+}
+
+// channelValue returns the value of the given channel (0=R, 1=G, 2=B, 3=A).
+func channelValue(c color.NRGBA, channel int) uint8 {
+	switch channel {
+	case 0:
+		return c.R
+	case 1:
+		return c.G
+	case 2:
+		return c.B
+	default:
+		return c.A
+	}
+}
+
+// averageColor returns the mean color of bucket.
+func averageColor(bucket []color.NRGBA) color.NRGBA {
+	var r, g, b, a int
+	for _, c := range bucket {
+		r += int(c.R)
+		g += int(c.G)
+		b += int(c.B)
+		a += int(c.A)
+	}
+
+	n := len(bucket)
+	if n == 0 {
+		return color.NRGBA{}
+	}
+
+	return color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)}
+}
+
+// quantizeImage reduces img to at most numColors colors via median-cut,
+// then dithers it into a freshly-allocated *image.Paletted with
+// Floyd-Steinberg error diffusion.
+func quantizeImage(img image.Image, numColors int) *image.Paletted {
+	palette := medianCutPalette(img, numColors)
+
+	bounds := img.Bounds()
+	dst := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette)
+	draw.FloydSteinberg.Draw(dst, dst.Bounds(), img, bounds.Min)
+
+	return dst
+}
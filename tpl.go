@@ -1,4 +1,6 @@
-package main
+// Package tpl reads and writes Nintendo's TPL texture format, used by the
+// GameCube and Wii for storing GX-native textures.
+package tpl
 
 import (
 	"bytes"
@@ -6,6 +8,7 @@ import (
 	"image"
 )
 
+// TPLMagic is the four-byte magic that begins every TPL file.
 const TPLMagic uint32 = 0x0020AF30
 
 type FileHeader struct {
@@ -37,6 +40,19 @@ type TPL struct {
 	Image      ImageHeader
 }
 
+// PaletteHeader precedes the TLUT entries of a palettized (CI4/CI8/CI14X2)
+// image, pointed to by TPL.PaletteOff.
+type PaletteHeader struct {
+	NumEntries uint16
+	Unpacked   uint8
+	Padding    uint8
+	Format     uint32
+	DataOffset uint32
+}
+
+// paletteHeaderSize is PaletteHeader's encoded byte size.
+const paletteHeaderSize = 12
+
 // TextureFormat is a format that an image can be converted into
 type TextureFormat uint32
 
@@ -48,12 +64,17 @@ const (
 	RGB565
 	RGB5A3
 	RGBA8
-	CI4 = 8
-	CI8 = 9
-	CI14X2
-	CMP = 14
+	CI4    = 8
+	CI8    = 9
+	CI14X2 = 10
+	CMP    = 14
 )
 
+// nonIndexedHeaderSize is the byte size of the FileHeader + offset table +
+// ImageHeader that makeTPLHeader writes before any non-palettized format's
+// pixel data, i.e. where DataOffset must point.
+const nonIndexedHeaderSize = 12 + 8 + 36
+
 // makeTPLHeader makes the TPL header.
 func makeTPLHeader(raw []byte, format TextureFormat, width, height int) ([]byte, error) {
 	buf := bytes.NewBuffer(nil)
@@ -70,7 +91,7 @@ func makeTPLHeader(raw []byte, format TextureFormat, width, height int) ([]byte,
 			Height:     uint16(height),
 			Width:      uint16(width),
 			Format:     uint32(format),
-			DataOffset: 64,
+			DataOffset: nonIndexedHeaderSize,
 			WrapS:      0,
 			WrapT:      0,
 			MinFilter:  1,